@@ -0,0 +1,89 @@
+package ext4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+
+	"github.com/dsoprea/go-logging"
+)
+
+// checksumOffset is where SChecksum lives in the serialized superblock;
+// the crc32c covers every byte before it.
+const checksumOffset = 1020
+
+var (
+	// ErrChecksumMismatch is returned (or, by ParseSuperblock unless
+	// ParseOptions.FailOnChecksumMismatch is set, only logged) when a
+	// superblock's recorded checksum doesn't match the crc32c of its own
+	// bytes.
+	ErrChecksumMismatch = errors.New("superblock checksum mismatch")
+
+	crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+// crc32c is the Castagnoli CRC-32 variant the kernel uses for every
+// ext4 metadata checksum (superblock, group descriptors, inodes,
+// extent trees, directory blocks). The kernel seeds it with ~0 and
+// does not invert the result, so it is the bitwise inverse of Go's
+// finalised crc32.Checksum.
+func crc32c(data []byte) uint32 {
+	return ^crc32.Checksum(data, crc32cTable)
+}
+
+// ChecksumSeed returns the seed callers should feed into crc32c when
+// validating group-descriptor, inode, extent-tree, and directory-block
+// checksums: SChecksumSeed when the filesystem stores one explicitly
+// (SbFeatureIncompatCsumSeed), and otherwise crc32c(sb.SUuid[:]), which
+// is what the kernel falls back to.
+func ChecksumSeed(sb *Superblock) uint32 {
+	if sb.HasIncompatibleFeature(SbFeatureIncompatCsumSeed) {
+		return sb.SChecksumSeed
+	}
+
+	return crc32c(sb.SUuid[:])
+}
+
+// VerifyChecksum recomputes the superblock's crc32c and compares it to
+// SChecksum. It is a no-op (returning nil) when
+// SbFeatureRoCompatMetadataCsum isn't set, since SChecksum is only
+// meaningful under that feature.
+func (sb *Superblock) VerifyChecksum() (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	if !sb.HasReadonlyCompatibleFeature(SbFeatureRoCompatMetadataCsum) {
+		return nil
+	}
+
+	buf := new(bytes.Buffer)
+
+	err = binary.Write(buf, binary.LittleEndian, sb)
+	log.PanicIf(err)
+
+	raw := buf.Bytes()
+	if len(raw) != SuperblockSize {
+		log.Panic(errors.New("serialized superblock is not SuperblockSize bytes"))
+	}
+
+	actual := crc32c(raw[:checksumOffset])
+
+	if actual != uint32(sb.SChecksum) {
+		log.Panic(ErrChecksumMismatch)
+	}
+
+	return nil
+}
+
+// ParseOptions controls ParseSuperblock's checksum handling.
+type ParseOptions struct {
+	// FailOnChecksumMismatch makes ParseSuperblock return
+	// ErrChecksumMismatch instead of only warning on stderr when
+	// SbFeatureRoCompatMetadataCsum is set and the checksum doesn't
+	// verify.
+	FailOnChecksumMismatch bool
+}
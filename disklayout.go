@@ -0,0 +1,111 @@
+package ext4
+
+import "time"
+
+// SuperBlock is a version-agnostic view over an ext2/ext3/ext4
+// superblock: the geometry and feature-set accessors a caller needs to
+// walk a filesystem without first working out for itself which fields
+// on the underlying Superblock are actually valid for this revision
+// (plain uint32 access to SBlocksCountHi on a non-64bit filesystem, for
+// example, is meaningless, and EXT2_GOOD_OLD_REV filesystems don't have
+// a feature set at all). This mirrors how gVisor's disklayout package
+// splits the same problem.
+//
+// NewSuperBlock picks the right implementation for a parsed Superblock.
+// ParseSuperblock itself still returns the concrete *Superblock that
+// the rest of this module (and the journal/tar2ext4/ext4fs packages)
+// already depend on directly; SuperBlock is additive for callers that
+// want a version-agnostic read and don't need the rest of the raw
+// struct.
+type SuperBlock interface {
+	InodesCount() uint32
+	BlocksCount() uint64
+	BlockSize() uint32
+	FirstDataBlock() uint32
+	InodeSize() uint32
+	RevLevel() uint32
+	MountTime() time.Time
+
+	HasCompatibleFeature(mask uint32) bool
+	HasReadonlyCompatibleFeature(mask uint32) bool
+	HasIncompatibleFeature(mask uint32) bool
+}
+
+// NewSuperBlock selects the SuperBlock view appropriate to sb's
+// revision and feature set.
+func NewSuperBlock(sb *Superblock) SuperBlock {
+	if !sb.HasExtended() {
+		return SuperBlockOldRev{sb}
+	}
+
+	if sb.HasIncompatibleFeature(SbFeatureIncompat64bit) {
+		return SuperBlockV4{sb}
+	}
+
+	return SuperBlockV2{sb}
+}
+
+// SuperBlockOldRev wraps a Superblock parsed from an EXT2_GOOD_OLD_REV
+// (revision 0) filesystem, which predates the feature set and the rest
+// of the extended fields entirely: inodes are always the historical
+// fixed 128 bytes, and there is no feature set to query.
+type SuperBlockOldRev struct {
+	*Superblock
+}
+
+func (sb SuperBlockOldRev) InodesCount() uint32    { return sb.Superblock.SInodesCount }
+func (sb SuperBlockOldRev) BlocksCount() uint64    { return uint64(sb.Superblock.SBlocksCountLo) }
+func (sb SuperBlockOldRev) FirstDataBlock() uint32 { return sb.Superblock.SFirstDataBlock }
+func (sb SuperBlockOldRev) InodeSize() uint32      { return 128 }
+func (sb SuperBlockOldRev) RevLevel() uint32       { return sb.Superblock.SRevLevel }
+
+func (sb SuperBlockOldRev) HasCompatibleFeature(mask uint32) bool         { return false }
+func (sb SuperBlockOldRev) HasReadonlyCompatibleFeature(mask uint32) bool { return false }
+func (sb SuperBlockOldRev) HasIncompatibleFeature(mask uint32) bool       { return false }
+
+// SuperBlockV2 wraps a Superblock parsed from an EXT2_DYNAMIC_REV
+// filesystem without the 64bit feature: the extended fields and the
+// feature set are valid, but SBlocksCountHi and friends are not, so
+// BlocksCount never looks at them.
+type SuperBlockV2 struct {
+	*Superblock
+}
+
+func (sb SuperBlockV2) InodesCount() uint32    { return sb.Superblock.SInodesCount }
+func (sb SuperBlockV2) BlocksCount() uint64    { return uint64(sb.Superblock.SBlocksCountLo) }
+func (sb SuperBlockV2) FirstDataBlock() uint32 { return sb.Superblock.SFirstDataBlock }
+func (sb SuperBlockV2) RevLevel() uint32       { return sb.Superblock.SRevLevel }
+
+func (sb SuperBlockV2) InodeSize() uint32 {
+	if sb.Superblock.SInodeSize > 0 {
+		return uint32(sb.Superblock.SInodeSize)
+	}
+
+	return 128
+}
+
+// SuperBlockV4 wraps a Superblock parsed from an EXT2_DYNAMIC_REV
+// filesystem with the 64bit feature set: the only revision where
+// SBlocksCountHi (and the rest of the *Hi fields) hold real data
+// instead of padding, letting BlocksCount report the true size of
+// multi-TiB filesystems that SBlocksCountLo alone would silently
+// truncate.
+type SuperBlockV4 struct {
+	*Superblock
+}
+
+func (sb SuperBlockV4) InodesCount() uint32    { return sb.Superblock.SInodesCount }
+func (sb SuperBlockV4) FirstDataBlock() uint32 { return sb.Superblock.SFirstDataBlock }
+func (sb SuperBlockV4) RevLevel() uint32       { return sb.Superblock.SRevLevel }
+
+func (sb SuperBlockV4) BlocksCount() uint64 {
+	return uint64(sb.Superblock.SBlocksCountLo) | uint64(sb.Superblock.SBlocksCountHi)<<32
+}
+
+func (sb SuperBlockV4) InodeSize() uint32 {
+	if sb.Superblock.SInodeSize > 0 {
+		return uint32(sb.Superblock.SInodeSize)
+	}
+
+	return 128
+}
@@ -0,0 +1,15 @@
+package ext4fs
+
+import "errors"
+
+var (
+	errNotDir      = errors.New("ext4fs: not a directory")
+	errIsDir       = errors.New("ext4fs: is a directory")
+	errSymlinkLoop = errors.New("ext4fs: too many levels of symbolic links")
+	errBadHtree    = errors.New("ext4fs: malformed htree index")
+
+	// ErrNoJournal is returned by Journal when the superblock has no
+	// journal inode (SJournalInum is 0), e.g. a filesystem mounted
+	// without journaling.
+	ErrNoJournal = errors.New("ext4fs: filesystem has no journal")
+)
@@ -0,0 +1,228 @@
+// Package ext4fs layers io/fs.FS on top of this module's Superblock
+// parser, following the pattern of gexto's NewFileSystem/List/Open:
+// where the rest of this module stops at exposing structs for the raw
+// on-disk metadata, ext4fs walks group descriptors, inodes, and extent
+// trees so an ext4 image can be used anywhere an io/fs.FS is expected
+// (fs.WalkDir, archiving, scanning) without the caller having to know
+// about the format at all.
+package ext4fs
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hellin/go-ext4"
+)
+
+// rootInode is the well-known inode number of the filesystem root.
+const rootInode = 2
+
+// FS is a read-only view of an ext4 image.
+type FS struct {
+	r  io.ReaderAt
+	sb *ext4.Superblock
+
+	blockSize uint32
+	groups    []groupDescriptor
+}
+
+var (
+	_ fs.FS         = (*FS)(nil)
+	_ fs.ReadDirFS  = (*FS)(nil)
+	_ fs.StatFS     = (*FS)(nil)
+	_ fs.ReadFileFS = (*FS)(nil)
+)
+
+// Open parses the superblock and group descriptor table from `r` and
+// returns an *FS ready to serve io/fs.FS calls.
+func Open(r io.ReaderAt) (*FS, error) {
+	sb, err := ext4.ParseSuperblock(io.NewSectionReader(r, ext4.Superblock0Offset, ext4.SuperblockSize))
+	if err != nil {
+		return nil, err
+	}
+
+	blockSize := sb.BlockSize()
+
+	groups, err := readGroupDescriptors(r, sb, blockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FS{r: r, sb: sb, blockSize: blockSize, groups: groups}, nil
+}
+
+// Superblock returns the parsed superblock backing this FS, for
+// callers that want to inspect feature bits or geometry directly.
+func (f *FS) Superblock() *ext4.Superblock {
+	return f.sb
+}
+
+// resolve walks `name` (an io/fs-style slash-separated path, "." for
+// the root) from the root inode, transparently following symlinks --
+// both intermediate path components and the final one, since io/fs has
+// no notion of an unresolved symlink -- up to a bounded depth to guard
+// against loops.
+func (f *FS) resolve(name string) (*inode, uint32, error) {
+	if !fs.ValidPath(name) {
+		return nil, 0, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	return f.resolveFrom(rootInode, name, 0)
+}
+
+const maxSymlinkDepth = 16
+
+func (f *FS) resolveFrom(dirIno uint32, name string, symlinkDepth int) (*inode, uint32, error) {
+	ino := dirIno
+
+	in, err := f.readInode(ino)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if name == "." {
+		return in, ino, nil
+	}
+
+	parts := strings.Split(name, "/")
+
+	for _, part := range parts {
+		if !in.isDir() {
+			return nil, 0, &fs.PathError{Op: "open", Path: name, Err: errNotDir}
+		}
+
+		parentIno := ino
+
+		entries, err := f.readDirEntries(in)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		next, ok := entries[part]
+		if !ok {
+			return nil, 0, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+
+		in, err = f.readInode(next.ino)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		ino = next.ino
+
+		if in.isSymlink() {
+			if symlinkDepth >= maxSymlinkDepth {
+				return nil, 0, &fs.PathError{Op: "open", Path: name, Err: errSymlinkLoop}
+			}
+
+			target, err := f.readSymlink(in)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			// A relative target is resolved against the directory
+			// that contains the symlink, exactly as the kernel does.
+			startIno := uint32(rootInode)
+			lookup := strings.TrimPrefix(target, "/")
+
+			if !strings.HasPrefix(target, "/") {
+				startIno = parentIno
+				lookup = path.Join(".", target)
+			}
+
+			in, ino, err = f.resolveFrom(startIno, lookup, symlinkDepth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+	}
+
+	return in, ino, nil
+}
+
+// Open implements io/fs.FS.
+func (f *FS) Open(name string) (fs.File, error) {
+	in, ino, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.isDir() {
+		entries, err := f.readDirEntries(in)
+		if err != nil {
+			return nil, err
+		}
+
+		return newOpenDir(f, name, in, ino, entries), nil
+	}
+
+	return newOpenFile(f, name, in, ino), nil
+}
+
+// Stat implements io/fs.StatFS.
+func (f *FS) Stat(name string) (fs.FileInfo, error) {
+	in, _, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo{name: path.Base(name), in: in}, nil
+}
+
+// ReadFile implements io/fs.ReadFileFS.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	in, ino, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if in.isDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: errIsDir}
+	}
+
+	return f.readFileData(in, ino)
+}
+
+// ReadDir implements io/fs.ReadDirFS.
+func (f *FS) ReadDir(name string) ([]fs.DirEntry, error) {
+	in, _, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if !in.isDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: errNotDir}
+	}
+
+	entries, err := f.readDirEntries(in)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]fs.DirEntry, 0, len(entries))
+
+	for name, e := range entries {
+		if name == "." || name == ".." {
+			continue
+		}
+
+		childInode, err := f.readInode(e.ino)
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, dirEntry{name: name, in: childInode})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+
+	return out, nil
+}
+
+func modTime(sec uint32) time.Time {
+	return time.Unix(int64(sec), 0)
+}
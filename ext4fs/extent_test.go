@@ -0,0 +1,99 @@
+package ext4fs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// buildExtentInode lays out a depth-0 extent tree header plus the given
+// leaf runs directly in a 60-byte i_block area, the same shape
+// writeExtentHeader in tar2ext4 produces.
+func buildExtentInode(t *testing.T, size uint64, runs []extentRun) *inode {
+	t.Helper()
+
+	raw := make([]byte, 128)
+	binary.LittleEndian.PutUint32(raw[32:36], inodeFlagExtents)
+	binary.LittleEndian.PutUint32(raw[4:8], uint32(size))
+
+	iBlock := raw[40:100]
+	binary.LittleEndian.PutUint16(iBlock[0:2], extentHeaderMagic)
+	binary.LittleEndian.PutUint16(iBlock[2:4], uint16(len(runs)))
+	binary.LittleEndian.PutUint16(iBlock[4:6], extentsPerInodeTest)
+	binary.LittleEndian.PutUint16(iBlock[6:8], 0) // depth
+
+	for i, run := range runs {
+		entry := iBlock[12+i*12 : 12+(i+1)*12]
+
+		count := uint16(run.blockCount)
+		if run.uninitialized {
+			count |= 0x8000
+		}
+
+		binary.LittleEndian.PutUint32(entry[0:4], run.logicalBlock)
+		binary.LittleEndian.PutUint16(entry[4:6], count)
+		binary.LittleEndian.PutUint16(entry[6:8], uint16(run.physicalBlock>>32))
+		binary.LittleEndian.PutUint32(entry[8:12], uint32(run.physicalBlock))
+	}
+
+	return &inode{raw: raw, size: size, flags: inodeFlagExtents}
+}
+
+const extentsPerInodeTest = 4
+
+// TestReadBlocksFillsHoles covers a sparse file: a leaf extent at
+// logical block 0 followed by one at logical block 2, leaving logical
+// block 1 a hole that must read back as zeroes rather than shifting
+// block 2's data left.
+func TestReadBlocksFillsHoles(t *testing.T) {
+	const blockSize = 1024
+
+	block0 := bytes.Repeat([]byte{0x11}, blockSize)
+	block2 := bytes.Repeat([]byte{0x22}, blockSize)
+
+	device := make([]byte, 3*blockSize)
+	copy(device[0*blockSize:], block0)
+	copy(device[2*blockSize:], block2)
+
+	in := buildExtentInode(t, 3*blockSize, []extentRun{
+		{logicalBlock: 0, physicalBlock: 0, blockCount: 1},
+		{logicalBlock: 2, physicalBlock: 2, blockCount: 1},
+	})
+
+	f := &FS{r: bytes.NewReader(device), blockSize: blockSize}
+
+	got, err := f.readBlocks(in)
+	if err != nil {
+		t.Fatalf("readBlocks: %v", err)
+	}
+
+	want := append(append(append([]byte{}, block0...), make([]byte, blockSize)...), block2...)
+	if !bytes.Equal(got, want) {
+		t.Errorf("readBlocks returned data that doesn't match the expected hole-filled layout")
+	}
+}
+
+// TestReadBlocksUninitializedExtent covers a preallocated-but-unwritten
+// extent: its physical blocks may hold stale data from a previously
+// deleted file, so readBlocks must return zeroes rather than reading
+// through to disk.
+func TestReadBlocksUninitializedExtent(t *testing.T) {
+	const blockSize = 1024
+
+	device := bytes.Repeat([]byte{0xFF}, blockSize)
+
+	in := buildExtentInode(t, blockSize, []extentRun{
+		{logicalBlock: 0, physicalBlock: 0, blockCount: 1, uninitialized: true},
+	})
+
+	f := &FS{r: bytes.NewReader(device), blockSize: blockSize}
+
+	got, err := f.readBlocks(in)
+	if err != nil {
+		t.Fatalf("readBlocks: %v", err)
+	}
+
+	if !bytes.Equal(got, make([]byte, blockSize)) {
+		t.Errorf("readBlocks returned stale bytes for an uninitialized extent")
+	}
+}
@@ -0,0 +1,170 @@
+package ext4fs
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+)
+
+// readFileData returns the full contents of a regular file (or, when
+// called from readSymlink, a slow symlink's target).
+func (f *FS) readFileData(in *inode, ino uint32) ([]byte, error) {
+	if in.hasInlineData() {
+		data := inlineData(in)
+		if uint64(len(data)) > in.size {
+			data = data[:in.size]
+		}
+
+		return data, nil
+	}
+
+	return f.readBlocks(in)
+}
+
+// openFile implements io/fs.File for a regular file opened via FS.Open.
+type openFile struct {
+	f    *FS
+	name string
+	in   *inode
+	ino  uint32
+
+	r *bytes.Reader
+}
+
+func newOpenFile(f *FS, name string, in *inode, ino uint32) *openFile {
+	return &openFile{f: f, name: name, in: in, ino: ino}
+}
+
+func (of *openFile) Stat() (fs.FileInfo, error) {
+	return fileInfo{name: path.Base(of.name), in: of.in}, nil
+}
+
+func (of *openFile) Read(p []byte) (int, error) {
+	if of.r == nil {
+		data, err := of.f.readFileData(of.in, of.ino)
+		if err != nil {
+			return 0, err
+		}
+
+		of.r = bytes.NewReader(data)
+	}
+
+	n, err := of.r.Read(p)
+	if err == io.EOF {
+		return n, io.EOF
+	}
+
+	return n, err
+}
+
+func (of *openFile) Close() error { return nil }
+
+// openDir implements io/fs.ReadDirFile for a directory opened via
+// FS.Open.
+type openDir struct {
+	f    *FS
+	name string
+	in   *inode
+	ino  uint32
+
+	entries []fs.DirEntry
+	read    int
+}
+
+func newOpenDir(f *FS, name string, in *inode, ino uint32, entries map[string]direntRef) *openDir {
+	out := make([]fs.DirEntry, 0, len(entries))
+
+	for entryName, e := range entries {
+		if entryName == "." || entryName == ".." {
+			continue
+		}
+
+		out = append(out, &lazyDirEntry{f: f, name: entryName, ino: e.ino})
+	}
+
+	sort.Slice(out, func(i, j int) bool { return out[i].Name() < out[j].Name() })
+
+	return &openDir{f: f, name: name, in: in, ino: ino, entries: out}
+}
+
+func (od *openDir) Stat() (fs.FileInfo, error) {
+	return fileInfo{name: path.Base(od.name), in: od.in}, nil
+}
+
+func (od *openDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: od.name, Err: errIsDir}
+}
+
+func (od *openDir) Close() error { return nil }
+
+func (od *openDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	remaining := od.entries[od.read:]
+
+	if n <= 0 {
+		od.read = len(od.entries)
+		return remaining, nil
+	}
+
+	if len(remaining) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(remaining) {
+		n = len(remaining)
+	}
+
+	od.read += n
+
+	return remaining[:n], nil
+}
+
+// lazyDirEntry defers reading the target inode until the caller
+// actually asks for type/info, so a plain ReadDir doesn't pay for every
+// child inode up front.
+type lazyDirEntry struct {
+	f    *FS
+	name string
+	ino  uint32
+
+	in *inode
+}
+
+func (d *lazyDirEntry) resolve() (*inode, error) {
+	if d.in == nil {
+		in, err := d.f.readInode(d.ino)
+		if err != nil {
+			return nil, err
+		}
+
+		d.in = in
+	}
+
+	return d.in, nil
+}
+
+func (d *lazyDirEntry) Name() string { return d.name }
+
+func (d *lazyDirEntry) IsDir() bool {
+	in, err := d.resolve()
+	return err == nil && in.isDir()
+}
+
+func (d *lazyDirEntry) Type() fs.FileMode {
+	in, err := d.resolve()
+	if err != nil {
+		return 0
+	}
+
+	return fileInfo{name: d.name, in: in}.Mode().Type()
+}
+
+func (d *lazyDirEntry) Info() (fs.FileInfo, error) {
+	in, err := d.resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	return fileInfo{name: d.name, in: in}, nil
+}
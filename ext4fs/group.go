@@ -0,0 +1,62 @@
+package ext4fs
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/hellin/go-ext4"
+)
+
+// groupDescriptor is the subset of ext4_group_desc this package needs
+// to locate an inode: which block holds the inode table for the group,
+// and (when 64bit is set) the high half of that block number.
+type groupDescriptor struct {
+	inodeTableLo uint32
+	inodeTableHi uint32
+}
+
+func (gd groupDescriptor) inodeTable() uint64 {
+	return uint64(gd.inodeTableLo) | uint64(gd.inodeTableHi)<<32
+}
+
+// readGroupDescriptors reads the group descriptor table, which
+// immediately follows the (block-aligned) superblock.
+func readGroupDescriptors(r io.ReaderAt, sb *ext4.Superblock, blockSize uint32) ([]groupDescriptor, error) {
+	blocksCount := uint64(sb.SBlocksCountLo)
+	if sb.HasFeature64bit() {
+		blocksCount |= uint64(sb.SBlocksCountHi) << 32
+	}
+
+	groupCount := (blocksCount + uint64(sb.SBlocksPerGroup) - 1) / uint64(sb.SBlocksPerGroup)
+
+	descSize := uint32(32)
+	if sb.HasFeature64bit() && sb.SDescSize > 32 {
+		descSize = uint32(sb.SDescSize)
+	}
+
+	// The group descriptor table starts in the block right after the
+	// one holding the superblock, which is always SFirstDataBlock (0
+	// for block sizes above 1K, where the superblock sits at byte
+	// offset 1024 within block 0; 1 for a 1K block size, where block 0
+	// is the boot sector).
+	gdBlock := uint64(sb.SFirstDataBlock) + 1
+
+	raw := make([]byte, groupCount*uint64(descSize))
+	if _, err := r.ReadAt(raw, int64(gdBlock)*int64(blockSize)); err != nil {
+		return nil, err
+	}
+
+	groups := make([]groupDescriptor, groupCount)
+
+	for i := range groups {
+		entry := raw[uint64(i)*uint64(descSize) : uint64(i+1)*uint64(descSize)]
+
+		groups[i].inodeTableLo = binary.LittleEndian.Uint32(entry[8:12])
+
+		if descSize > 32 {
+			groups[i].inodeTableHi = binary.LittleEndian.Uint32(entry[40:44])
+		}
+	}
+
+	return groups, nil
+}
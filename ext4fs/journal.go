@@ -0,0 +1,30 @@
+package ext4fs
+
+import (
+	"bytes"
+
+	"github.com/hellin/go-ext4/journal"
+)
+
+// Journal resolves the filesystem's journal inode (Superblock.SJournalInum)
+// to its data blocks and hands the resulting byte stream to journal.Open,
+// the piece that package doesn't do itself since it has no inode/extent
+// parsing of its own.
+func (f *FS) Journal() (*journal.Journal, error) {
+	ino := f.sb.SJournalInum
+	if ino == 0 {
+		return nil, ErrNoJournal
+	}
+
+	in, err := f.readInode(ino)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := f.readBlocks(in)
+	if err != nil {
+		return nil, err
+	}
+
+	return journal.Open(bytes.NewReader(data))
+}
@@ -0,0 +1,24 @@
+package ext4fs
+
+// readSymlink returns a symlink inode's target.
+//
+// A "fast" symlink (the common case for short targets) never has any
+// blocks allocated -- the target is stored directly in the 60-byte
+// i_block area. A "slow" symlink instead has its target written out
+// like regular file data and read back through the extent tree.
+func (f *FS) readSymlink(in *inode) (string, error) {
+	if in.size == 0 {
+		return "", nil
+	}
+
+	if !in.hasExtents() && !in.hasInlineData() && in.size <= uint64(len(in.iBlock())) {
+		return string(in.iBlock()[:in.size]), nil
+	}
+
+	data, err := f.readFileData(in, 0)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
@@ -0,0 +1,84 @@
+package ext4fs
+
+import "encoding/binary"
+
+// ibodyXattrMagic marks the start of the in-inode extended attribute
+// area, at raw[128+extraIsize:].
+const ibodyXattrMagic = 0xEA020000
+
+// Xattrs returns the extended attributes stored inline in the inode
+// for `name`. Attributes that spill into an external xattr block
+// (referenced by i_file_acl, for values too large to fit in the inode)
+// are not read by this package.
+func (f *FS) Xattrs(name string) (map[string][]byte, error) {
+	in, _, err := f.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return readIbodyXattrs(in)
+}
+
+// xattrPrefixes mirrors the kernel's e_name_index name-index table for
+// the prefixes this package is likely to see.
+var xattrPrefixes = map[byte]string{
+	1: "user.",
+	2: "system.posix_acl_access",
+	3: "system.posix_acl_default",
+	4: "trusted.",
+	6: "security.",
+	7: "system.",
+}
+
+func readIbodyXattrs(in *inode) (map[string][]byte, error) {
+	out := make(map[string][]byte)
+
+	headerStart := 128 + int(in.extraIsize)
+	if headerStart+4 > len(in.raw) {
+		return out, nil
+	}
+
+	area := in.raw[headerStart:]
+	if binary.LittleEndian.Uint32(area[0:4]) != ibodyXattrMagic {
+		return out, nil
+	}
+
+	// Entries start after the 4-byte magic; values are stored from the
+	// end of `area` backwards, at e_value_offs relative to the entry
+	// table's start (i.e. relative to area[4:]).
+	entries := area[4:]
+	offset := 0
+
+	for offset+16 <= len(entries) {
+		nameLen := int(entries[offset])
+		nameIndex := entries[offset+1]
+
+		if nameLen == 0 && nameIndex == 0 {
+			break
+		}
+
+		valueOffs := int(binary.LittleEndian.Uint16(entries[offset+2 : offset+4]))
+		valueBlock := binary.LittleEndian.Uint32(entries[offset+4 : offset+8])
+		valueSize := int(binary.LittleEndian.Uint32(entries[offset+8 : offset+12]))
+
+		nameStart := offset + 16
+		if nameStart+nameLen > len(entries) {
+			break
+		}
+
+		name := xattrPrefixes[nameIndex] + string(entries[nameStart:nameStart+nameLen])
+
+		if valueBlock == 0 && valueOffs+valueSize <= len(entries) {
+			value := make([]byte, valueSize)
+			copy(value, entries[valueOffs:valueOffs+valueSize])
+			out[name] = value
+		}
+
+		// Each entry is padded up to a 4-byte boundary.
+		entrySize := 16 + nameLen
+		entrySize = (entrySize + 3) &^ 3
+		offset += entrySize
+	}
+
+	return out, nil
+}
@@ -0,0 +1,105 @@
+package ext4
+
+// The methods below replace the "pass a bitmask to HasIncompatibleFeature"
+// idiom with one predicate per feature, hardcoded to the correct
+// compat/ro-compat/incompat word so callers can't accidentally test a
+// bit against the wrong bitmap (e.g. testing FlexBg, an incompat
+// feature, via HasReadonlyCompatibleFeature). This mirrors the
+// ext4_has_feature_*(sb) helpers the kernel grew for the same reason.
+
+// HasFeatureDirIndex reports whether directories are hashed (htree)
+// rather than a flat linear array of entries.
+func (sb *Superblock) HasFeatureDirIndex() bool {
+	return sb.HasCompatibleFeature(SbFeatureCompatDirIndex)
+}
+
+// HasFeatureExtents reports whether inodes store extent trees rather
+// than the legacy direct/indirect block pointers.
+func (sb *Superblock) HasFeatureExtents() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompatExtents)
+}
+
+// HasFeature64bit reports whether block/inode counts and group
+// descriptors carry the *Hi half needed to address more than 2^32
+// blocks.
+func (sb *Superblock) HasFeature64bit() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompat64bit)
+}
+
+// HasFeatureFlexBg reports whether block groups are packed into flex
+// groups, so their bitmaps/inode tables aren't necessarily within the
+// group itself.
+func (sb *Superblock) HasFeatureFlexBg() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompatFlexBg)
+}
+
+// HasFeatureMmp reports whether multi-mount protection is enabled.
+func (sb *Superblock) HasFeatureMmp() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompatMmp)
+}
+
+// HasFeatureEaInode reports whether extended attribute values may be
+// stored in a separate inode rather than inline/in an xattr block.
+func (sb *Superblock) HasFeatureEaInode() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompatEaInode)
+}
+
+// HasFeatureLargeDir reports whether directories may exceed 2GB or use
+// a 3-level htree.
+func (sb *Superblock) HasFeatureLargeDir() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompatLargeDir)
+}
+
+// HasFeatureInlineData reports whether small files/directories may
+// have their data stored directly in the inode.
+func (sb *Superblock) HasFeatureInlineData() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompatInlineData)
+}
+
+// HasFeatureEncrypt reports whether fscrypt per-directory encryption
+// may be in use.
+func (sb *Superblock) HasFeatureEncrypt() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompatEncrypt)
+}
+
+// HasFeatureCasefold reports whether case-insensitive directories are
+// enabled.
+func (sb *Superblock) HasFeatureCasefold() bool {
+	return sb.HasIncompatibleFeature(SbFeatureIncompatCasefold)
+}
+
+// HasFeatureMetadataCsum reports whether group descriptors, inodes,
+// extent trees, and directory blocks carry their own crc32c checksums.
+func (sb *Superblock) HasFeatureMetadataCsum() bool {
+	return sb.HasReadonlyCompatibleFeature(SbFeatureRoCompatMetadataCsum)
+}
+
+// HasFeatureExtraIsize reports whether inodes reserve extra space
+// beyond the base 128 bytes (SWantExtraIsize/SMinExtraIsize).
+func (sb *Superblock) HasFeatureExtraIsize() bool {
+	return sb.HasReadonlyCompatibleFeature(SbFeatureRoCompatExtraIsize)
+}
+
+// HasFeatureQuota reports whether usage/grace-time quota accounting is
+// tracked in hidden quota-file inodes.
+func (sb *Superblock) HasFeatureQuota() bool {
+	return sb.HasReadonlyCompatibleFeature(SbFeatureRoCompatQuota)
+}
+
+// HasFeatureBigalloc reports whether blocks are allocated in clusters
+// larger than one block.
+func (sb *Superblock) HasFeatureBigalloc() bool {
+	return sb.HasReadonlyCompatibleFeature(SbFeatureRoCompatBigalloc)
+}
+
+// HasFeatureProject reports whether per-inode project IDs and project
+// quota accounting are enabled.
+func (sb *Superblock) HasFeatureProject() bool {
+	return sb.HasReadonlyCompatibleFeature(SbFeatureRoCompatProject)
+}
+
+// HasFeatureVerity reports whether fs-verity (transparent read-only
+// integrity verification) may be enabled on individual files.
+func (sb *Superblock) HasFeatureVerity() bool {
+	return sb.HasReadonlyCompatibleFeature(SbFeatureRoCompatVerity)
+}
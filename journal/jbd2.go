@@ -0,0 +1,70 @@
+// Package journal parses and replays the JBD2 journal referenced by an
+// ext4 Superblock's SJournalInum, bringing a filesystem that was not
+// cleanly unmounted (SbStateCleanlyUnmounted clear, or
+// SbFeatureIncompatRecover set) back to a consistent state before other
+// packages in this module read from it.
+package journal
+
+import "errors"
+
+const (
+	// Jbd2Magic is h_magic on every journal block header (descriptor,
+	// commit, revoke and superblock alike).
+	Jbd2Magic = 0xc03b3998
+)
+
+// Block types, from the h_blocktype field of the journal block header.
+const (
+	Jbd2DescriptorBlock = 1
+	Jbd2CommitBlock     = 2
+	Jbd2SuperblockV1    = 3
+	Jbd2SuperblockV2    = 4
+	Jbd2RevokeBlock     = 5
+)
+
+// JBD2 incompat feature bits (s_feature_incompat). Any bit outside
+// knownIncompatFeatures (which leaves out FastCommit) is unsupported by
+// this package and, matching the kernel's own semantics for
+// incompatible feature sets, replay refuses to proceed rather than risk
+// misinterpreting the log.
+const (
+	Jbd2FeatureIncompatRevoke      = uint32(0x0001)
+	Jbd2FeatureIncompat64bit       = uint32(0x0002)
+	Jbd2FeatureIncompatAsyncCommit = uint32(0x0004)
+	Jbd2FeatureIncompatCsumV2      = uint32(0x0008)
+	Jbd2FeatureIncompatCsumV3      = uint32(0x0010)
+	Jbd2FeatureIncompatFastCommit  = uint32(0x0020)
+)
+
+// knownIncompatFeatures is the set of incompat bits this package
+// understands well enough to replay safely.
+const knownIncompatFeatures = Jbd2FeatureIncompatRevoke |
+	Jbd2FeatureIncompat64bit |
+	Jbd2FeatureIncompatAsyncCommit |
+	Jbd2FeatureIncompatCsumV2 |
+	Jbd2FeatureIncompatCsumV3
+
+var (
+	// ErrNotJbd2 is returned when a block's magic doesn't match Jbd2Magic.
+	ErrNotJbd2 = errors.New("journal: not a JBD2 block")
+
+	// ErrUnknownIncompatFeature is returned by Open when the journal
+	// superblock sets an incompat bit this package doesn't understand.
+	ErrUnknownIncompatFeature = errors.New("journal: unknown JBD2 incompat feature bit set")
+
+	// ErrTruncated is returned when a descriptor-block tag or a revoke
+	// block is too short to decode. A final transaction whose commit
+	// block is missing is not an error: it is dropped without being
+	// returned or replayed.
+	ErrTruncated = errors.New("journal: truncated transaction")
+)
+
+// blockHeader is the 12-byte header common to every block type in the
+// journal.
+type blockHeader struct {
+	Magic     uint32
+	BlockType uint32
+	Sequence  uint32
+}
+
+const blockHeaderSize = 12
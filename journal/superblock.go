@@ -0,0 +1,79 @@
+package journal
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// Superblock is the JBD2 journal superblock (journal_superblock_t),
+// read from the first block of the journal. Unlike ext4's own
+// Superblock, every multi-byte field on disk is big-endian.
+type Superblock struct {
+	Header blockHeader
+
+	// 0x0C
+	SBlocksize uint32 /* journal device blocksize */
+	SMaxlen    uint32 /* total blocks in journal */
+	SFirst     uint32 /* first block of log information */
+
+	// 0x18
+	SSequence uint32 /* first commit ID expected in log */
+	SStart    uint32 /* blocknr of start of log */
+	SErrno    int32
+
+	// 0x24 -- only valid for a V2 superblock.
+	SFeatureCompat   uint32
+	SFeatureIncompat uint32
+	SFeatureRoCompat uint32
+
+	SUuid [16]byte
+
+	SNrUsers uint32
+
+	SDynsuper uint32
+
+	SMaxTransaction uint32
+	SMaxTransData   uint32
+
+	SChecksumType uint8
+	SPadding2     [3]uint8
+	SNumFcBlks    uint32
+
+	SHead uint32
+
+	SPadding [40]uint32
+
+	SChecksum uint32
+
+	SUsers [16 * 48]uint8
+}
+
+// ParseSuperblock reads a JBD2 journal superblock from `r`, which must
+// be positioned at the first block of the journal inode/device.
+func ParseSuperblock(r io.Reader) (*Superblock, error) {
+	sb := new(Superblock)
+
+	if err := binary.Read(r, binary.BigEndian, sb); err != nil {
+		return nil, err
+	}
+
+	if sb.Header.Magic != Jbd2Magic {
+		return nil, ErrNotJbd2
+	}
+
+	if sb.Header.BlockType != Jbd2SuperblockV1 && sb.Header.BlockType != Jbd2SuperblockV2 {
+		return nil, ErrNotJbd2
+	}
+
+	return sb, nil
+}
+
+// IsV2 reports whether the superblock carries the version-2 fields
+// (feature sets, UUID, checksums) rather than just the bare v1 layout.
+func (sb *Superblock) IsV2() bool {
+	return sb.Header.BlockType == Jbd2SuperblockV2
+}
+
+func (sb *Superblock) hasIncompatFeature(mask uint32) bool {
+	return sb.IsV2() && sb.SFeatureIncompat&mask != 0
+}
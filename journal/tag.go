@@ -0,0 +1,91 @@
+package journal
+
+import "encoding/binary"
+
+// Block tag flags (t_flags on journal_block_tag_t / journal_block_tag3_t).
+const (
+	tagFlagEscape   = 0x1 /* on-disk block's first 4 bytes were Jbd2Magic and got replaced with zero */
+	tagFlagSameUuid = 0x2 /* tag doesn't carry its own UUID; use the one from the preceding tag */
+	tagFlagDeleted  = 0x4
+	tagFlagLastTag  = 0x8 /* last tag in this descriptor block */
+)
+
+// blockTag is one decoded descriptor-block tag: which on-disk block a
+// following journal block should be written to, and whether the 4
+// escaped magic-number bytes need restoring.
+type blockTag struct {
+	blockNr uint64
+	flags   uint32
+	last    bool
+}
+
+// readTag decodes one tag from a descriptor block, returning the tag
+// and how many bytes it (plus any embedded UUID) occupied, so the
+// caller can advance to the next one.
+func readTag(buf []byte, sb *Superblock) (blockTag, int, error) {
+	csumV3 := sb.hasIncompatFeature(Jbd2FeatureIncompatCsumV3)
+	csumV2 := sb.hasIncompatFeature(Jbd2FeatureIncompatCsumV2)
+	is64bit := sb.hasIncompatFeature(Jbd2FeatureIncompat64bit)
+
+	var tag blockTag
+	var n int
+
+	switch {
+	case csumV3:
+		// journal_block_tag3_t: blocknr, flags, [blocknr_high], [checksum]
+		if len(buf) < 12 {
+			return tag, 0, ErrTruncated
+		}
+
+		tag.blockNr = uint64(binary.BigEndian.Uint32(buf[0:4]))
+		tag.flags = binary.BigEndian.Uint32(buf[4:8])
+		n = 8
+
+		if is64bit {
+			if len(buf) < n+4 {
+				return tag, 0, ErrTruncated
+			}
+
+			tag.blockNr |= uint64(binary.BigEndian.Uint32(buf[n:n+4])) << 32
+			n += 4
+		}
+
+		n += 4 // t_checksum (not verified here)
+	default:
+		// journal_block_tag_t: blocknr, [checksum], flags, [blocknr_high]
+		if len(buf) < 8 {
+			return tag, 0, ErrTruncated
+		}
+
+		tag.blockNr = uint64(binary.BigEndian.Uint32(buf[0:4]))
+
+		if csumV2 {
+			tag.flags = uint32(binary.BigEndian.Uint16(buf[6:8]))
+		} else {
+			tag.flags = binary.BigEndian.Uint32(buf[4:8])
+		}
+
+		n = 8
+
+		if is64bit {
+			if len(buf) < n+4 {
+				return tag, 0, ErrTruncated
+			}
+
+			tag.blockNr |= uint64(binary.BigEndian.Uint32(buf[n:n+4])) << 32
+			n += 4
+		}
+	}
+
+	tag.last = tag.flags&tagFlagLastTag != 0
+
+	if tag.flags&tagFlagSameUuid == 0 {
+		if len(buf) < n+16 {
+			return tag, 0, ErrTruncated
+		}
+
+		n += 16
+	}
+
+	return tag, n, nil
+}
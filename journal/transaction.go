@@ -0,0 +1,224 @@
+package journal
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// BlockWrite is one block this transaction wrote: which on-disk block
+// to write it to, and the raw block data. Flagged "escaped" blocks
+// (those whose first 4 bytes collided with Jbd2Magic at commit time)
+// have already had their magic number restored.
+type BlockWrite struct {
+	BlockNr uint64
+	Data    []byte
+}
+
+// Transaction is one committed JBD2 transaction, decoded from its
+// descriptor block, the data blocks it covers, and its commit block.
+// It is exposed both so Replay can apply it and so forensic callers
+// can inspect what a (possibly still-dirty) image's journal recorded
+// without touching the filesystem itself.
+type Transaction struct {
+	Sequence   uint32
+	CommitTime time.Time
+	Blocks     []BlockWrite
+	Revoked    []uint64
+}
+
+// journalReader walks the circular log starting at a given logical
+// block, handing back one block at a time and wrapping from SMaxlen-1
+// back to block 1 (block 0 is always the superblock).
+type journalReader struct {
+	r         io.ReaderAt
+	sb        *Superblock
+	blockSize uint32
+	next      uint32
+}
+
+func newJournalReader(r io.ReaderAt, sb *Superblock, start uint32) *journalReader {
+	return &journalReader{r: r, sb: sb, blockSize: sb.SBlocksize, next: start}
+}
+
+func (jr *journalReader) readBlock() ([]byte, uint32, error) {
+	blockNr := jr.next
+
+	buf := make([]byte, jr.blockSize)
+	if _, err := jr.r.ReadAt(buf, int64(blockNr)*int64(jr.blockSize)); err != nil {
+		return nil, 0, err
+	}
+
+	jr.next++
+	if jr.next >= jr.sb.SMaxlen {
+		jr.next = 1
+	}
+
+	return buf, blockNr, nil
+}
+
+// readTransactions walks every committed transaction starting at
+// sb.SSequence/sb.SStart, stopping at the first block whose sequence
+// number doesn't match (the usual end-of-log marker) or whose magic
+// isn't Jbd2Magic (an uninitialized block). An I/O error from the
+// underlying ReaderAt also ends the walk rather than being returned;
+// that is only expected past the end of a short, in-memory test
+// fixture.
+func readTransactions(r io.ReaderAt, sb *Superblock) ([]Transaction, error) {
+	if sb.IsV2() && sb.SFeatureIncompat&^knownIncompatFeatures != 0 {
+		return nil, ErrUnknownIncompatFeature
+	}
+
+	jr := newJournalReader(r, sb, sb.SStart)
+
+	var transactions []Transaction
+	expected := sb.SSequence
+
+	for {
+		buf, _, err := jr.readBlock()
+		if err != nil {
+			break
+		}
+
+		var hdr blockHeader
+		hdr.Magic = binary.BigEndian.Uint32(buf[0:4])
+		hdr.BlockType = binary.BigEndian.Uint32(buf[4:8])
+		hdr.Sequence = binary.BigEndian.Uint32(buf[8:12])
+
+		if hdr.Magic != Jbd2Magic || hdr.Sequence != expected {
+			break
+		}
+
+		if hdr.BlockType != Jbd2DescriptorBlock {
+			// A bare revoke/commit block with no preceding descriptor
+			// shouldn't appear in a well-formed log; treat it as the
+			// end of usable data rather than erroring out.
+			break
+		}
+
+		txn, ok, err := readTransaction(jr, sb, buf, expected)
+		if err != nil {
+			return transactions, err
+		}
+
+		if !ok {
+			// Descriptor block present but its commit block never
+			// landed -- this is the transaction that was in flight
+			// when the system went down; it must not be replayed.
+			break
+		}
+
+		transactions = append(transactions, txn)
+		expected++
+	}
+
+	return transactions, nil
+}
+
+// readTransaction decodes the data/revoke blocks following a
+// descriptor block and consumes blocks up to and including the commit
+// block that closes the transaction. It returns ok=false if the
+// transaction is incomplete (no commit block found).
+func readTransaction(jr *journalReader, sb *Superblock, descriptor []byte, sequence uint32) (Transaction, bool, error) {
+	txn := Transaction{Sequence: sequence}
+
+	body := descriptor[blockHeaderSize:]
+
+	for {
+		tag, n, err := readTag(body, sb)
+		if err != nil {
+			return txn, false, err
+		}
+
+		data, _, err := jr.readBlock()
+		if err != nil {
+			return txn, false, nil
+		}
+
+		if tag.flags&tagFlagEscape != 0 {
+			binary.BigEndian.PutUint32(data[0:4], Jbd2Magic)
+		}
+
+		txn.Blocks = append(txn.Blocks, BlockWrite{BlockNr: tag.blockNr, Data: data})
+
+		if tag.last {
+			break
+		}
+
+		body = body[n:]
+		if len(body) < 8 {
+			return txn, false, nil
+		}
+	}
+
+	// One or more revoke blocks, then the commit block, may follow.
+	for {
+		buf, _, err := jr.readBlock()
+		if err != nil {
+			return txn, false, nil
+		}
+
+		hdr := blockHeader{
+			Magic:     binary.BigEndian.Uint32(buf[0:4]),
+			BlockType: binary.BigEndian.Uint32(buf[4:8]),
+			Sequence:  binary.BigEndian.Uint32(buf[8:12]),
+		}
+
+		if hdr.Magic != Jbd2Magic || hdr.Sequence != sequence {
+			return txn, false, nil
+		}
+
+		switch hdr.BlockType {
+		case Jbd2RevokeBlock:
+			revoked, err := readRevokeBlock(buf, sb)
+			if err != nil {
+				return txn, false, err
+			}
+
+			txn.Revoked = append(txn.Revoked, revoked...)
+		case Jbd2CommitBlock:
+			// commit_header: h_commit_sec/h_commit_nsec follow the
+			// checksum type/size/padding and the 32-byte h_chksum.
+			sec := binary.BigEndian.Uint64(buf[48:56])
+			nsec := binary.BigEndian.Uint32(buf[56:60])
+			txn.CommitTime = time.Unix(int64(sec), int64(nsec))
+
+			return txn, true, nil
+		default:
+			return txn, false, nil
+		}
+	}
+}
+
+// readRevokeBlock decodes a JFS_REVOKE_BLOCK: a header, an r_count
+// byte-length covering itself, and a packed array of revoked block
+// numbers (8 bytes each if JBD2_FEATURE_INCOMPAT_64BIT is set, else 4).
+func readRevokeBlock(buf []byte, sb *Superblock) ([]uint64, error) {
+	if len(buf) < blockHeaderSize+4 {
+		return nil, ErrTruncated
+	}
+
+	count := binary.BigEndian.Uint32(buf[blockHeaderSize : blockHeaderSize+4])
+
+	entrySize := 4
+	if sb.hasIncompatFeature(Jbd2FeatureIncompat64bit) {
+		entrySize = 8
+	}
+
+	off := blockHeaderSize + 4
+	end := int(count)
+
+	var revoked []uint64
+
+	for off+entrySize <= end && off+entrySize <= len(buf) {
+		if entrySize == 8 {
+			revoked = append(revoked, binary.BigEndian.Uint64(buf[off:off+8]))
+		} else {
+			revoked = append(revoked, uint64(binary.BigEndian.Uint32(buf[off:off+4])))
+		}
+
+		off += entrySize
+	}
+
+	return revoked, nil
+}
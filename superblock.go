@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"math"
+	"os"
 	"time"
 
 	"encoding/binary"
@@ -247,6 +248,12 @@ func (sb *Superblock) HasIncompatibleFeature(mask uint32) bool {
 	return (sb.SFeatureIncompat & mask) > 0
 }
 
+// AsSuperBlock returns the version-agnostic SuperBlock view appropriate
+// to this superblock's revision and feature set. See NewSuperBlock.
+func (sb *Superblock) AsSuperBlock() SuperBlock {
+	return NewSuperBlock(sb)
+}
+
 func (sb *Superblock) Dump() {
 	fmt.Printf("Superblock Info\n")
 	fmt.Printf("\n")
@@ -319,6 +326,7 @@ const (
 	SbFeatureCompatExtAttr      = uint32(0x0008)
 	SbFeatureCompatResizeInode  = uint32(0x0010)
 	SbFeatureCompatDirIndex     = uint32(0x0020)
+	SbFeatureCompatSparseSuper2 = uint32(0x0200)
 )
 
 var (
@@ -329,6 +337,7 @@ var (
 		"HasJournal",
 		"ImagicInodes",
 		"ResizeInode",
+		"SparseSuper2",
 	}
 
 	SbFeatureCompatLookup = map[string]uint32{
@@ -338,38 +347,54 @@ var (
 		"ExtAttr":      SbFeatureCompatExtAttr,
 		"ResizeInode":  SbFeatureCompatResizeInode,
 		"DirIndex":     SbFeatureCompatDirIndex,
+		"SparseSuper2": SbFeatureCompatSparseSuper2,
 	}
 )
 
 const (
-	SbFeatureRoCompatSparseSuper = uint32(0x0001)
-	SbFeatureRoCompatLargeFile   = uint32(0x0002)
-	SbFeatureRoCompatBtreeDir    = uint32(0x0004)
-	SbFeatureRoCompatHugeFile    = uint32(0x0008)
-	SbFeatureRoCompatGdtCsum     = uint32(0x0010)
-	SbFeatureRoCompatDirNlink    = uint32(0x0020)
-	SbFeatureRoCompatExtraIsize  = uint32(0x0040)
+	SbFeatureRoCompatSparseSuper  = uint32(0x0001)
+	SbFeatureRoCompatLargeFile    = uint32(0x0002)
+	SbFeatureRoCompatBtreeDir     = uint32(0x0004)
+	SbFeatureRoCompatHugeFile     = uint32(0x0008)
+	SbFeatureRoCompatGdtCsum      = uint32(0x0010)
+	SbFeatureRoCompatDirNlink     = uint32(0x0020)
+	SbFeatureRoCompatExtraIsize   = uint32(0x0040)
+	SbFeatureRoCompatQuota        = uint32(0x0100)
+	SbFeatureRoCompatBigalloc     = uint32(0x0200)
+	SbFeatureRoCompatMetadataCsum = uint32(0x0400)
+	SbFeatureRoCompatProject      = uint32(0x2000)
+	SbFeatureRoCompatVerity       = uint32(0x8000)
 )
 
 var (
 	SbFeatureRoCompatNames = []string{
+		"Bigalloc",
 		"BtreeDir",
 		"DirNlink",
 		"ExtraIsize",
 		"GdtCsum",
 		"HugeFile",
 		"LargeFile",
+		"MetadataCsum",
+		"Project",
+		"Quota",
 		"SparseSuper",
+		"Verity",
 	}
 
 	SbFeatureRoCompatLookup = map[string]uint32{
-		"SparseSuper": SbFeatureRoCompatSparseSuper,
-		"LargeFile":   SbFeatureRoCompatLargeFile,
-		"BtreeDir":    SbFeatureRoCompatBtreeDir,
-		"HugeFile":    SbFeatureRoCompatHugeFile,
-		"GdtCsum":     SbFeatureRoCompatGdtCsum,
-		"DirNlink":    SbFeatureRoCompatDirNlink,
-		"ExtraIsize":  SbFeatureRoCompatExtraIsize,
+		"SparseSuper":  SbFeatureRoCompatSparseSuper,
+		"LargeFile":    SbFeatureRoCompatLargeFile,
+		"BtreeDir":     SbFeatureRoCompatBtreeDir,
+		"HugeFile":     SbFeatureRoCompatHugeFile,
+		"GdtCsum":      SbFeatureRoCompatGdtCsum,
+		"DirNlink":     SbFeatureRoCompatDirNlink,
+		"ExtraIsize":   SbFeatureRoCompatExtraIsize,
+		"Quota":        SbFeatureRoCompatQuota,
+		"Bigalloc":     SbFeatureRoCompatBigalloc,
+		"MetadataCsum": SbFeatureRoCompatMetadataCsum,
+		"Project":      SbFeatureRoCompatProject,
+		"Verity":       SbFeatureRoCompatVerity,
 	}
 )
 
@@ -383,16 +408,28 @@ const (
 	SbFeatureIncompat64bit       = uint32(0x0080)
 	SbFeatureIncompatMmp         = uint32(0x0100)
 	SbFeatureIncompatFlexBg      = uint32(0x0200)
+	SbFeatureIncompatEaInode     = uint32(0x0400)
+	SbFeatureIncompatCsumSeed    = uint32(0x2000) /* sb stores a checksum seed independent of SUuid */
+	SbFeatureIncompatLargeDir    = uint32(0x4000) /* >2GB or 3-level htree */
+	SbFeatureIncompatInlineData  = uint32(0x8000) /* data in inode */
+	SbFeatureIncompatEncrypt     = uint32(0x10000)
+	SbFeatureIncompatCasefold    = uint32(0x20000000)
 )
 
 var (
 	SbFeatureIncompatNames = []string{
 		"64bit",
+		"Casefold",
 		"Compression",
+		"CsumSeed",
+		"EaInode",
+		"Encrypt",
 		"Extents",
 		"Filetype",
 		"FlexBg",
+		"InlineData",
 		"JournalDev",
+		"LargeDir",
 		"MetaBg",
 		"Mmp",
 		"Recover",
@@ -407,11 +444,21 @@ var (
 		"Extents":     SbFeatureIncompatExtents,
 		"64bit":       SbFeatureIncompat64bit,
 		"Mmp":         SbFeatureIncompatMmp,
+		"CsumSeed":    SbFeatureIncompatCsumSeed,
 		"FlexBg":      SbFeatureIncompatFlexBg,
+		"EaInode":     SbFeatureIncompatEaInode,
+		"LargeDir":    SbFeatureIncompatLargeDir,
+		"InlineData":  SbFeatureIncompatInlineData,
+		"Encrypt":     SbFeatureIncompatEncrypt,
+		"Casefold":    SbFeatureIncompatCasefold,
 	}
 )
 
-func ParseSuperblock(r io.Reader) (sb *Superblock, err error) {
+// ParseSuperblock reads a superblock from `r`. By default, a
+// metadata-checksum mismatch is only printed to stderr; pass a
+// ParseOptions with FailOnChecksumMismatch set to return
+// ErrChecksumMismatch instead.
+func ParseSuperblock(r io.Reader, o ...ParseOptions) (sb *Superblock, err error) {
 	defer func() {
 		if state := recover(); state != nil {
 			err := log.Wrap(state.(error))
@@ -428,5 +475,18 @@ func ParseSuperblock(r io.Reader) (sb *Superblock, err error) {
 		log.Panic(ErrNotExt4)
 	}
 
+	var options ParseOptions
+	if len(o) > 0 {
+		options = o[0]
+	}
+
+	if err := sb.VerifyChecksum(); err != nil {
+		if options.FailOnChecksumMismatch {
+			log.Panic(err)
+		}
+
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", err)
+	}
+
 	return sb, nil
 }
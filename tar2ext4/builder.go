@@ -0,0 +1,489 @@
+package tar2ext4
+
+import (
+	"archive/tar"
+	"encoding/binary"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/dsoprea/go-logging"
+
+	"github.com/hellin/go-ext4"
+)
+
+const (
+	// rootInode and lostAndFoundInode are the well-known inode numbers
+	// the kernel expects; inodes 1-10 are reserved.
+	rootInode         = 2
+	lostAndFoundInode = 11
+	firstFreeInode    = 12
+
+	defaultInodeSize = 256
+)
+
+var (
+	// ErrImageTooLarge is returned when the tar stream doesn't fit in
+	// the single block group this builder lays out.
+	ErrImageTooLarge = errors.New("tar2ext4: data does not fit in image")
+
+	// ErrFileTooFragmented is returned when a single file needs more
+	// blocks than fit in one ext4_extent (32768); this builder only
+	// ever emits one extent per file, since its block allocator always
+	// hands out a contiguous run.
+	ErrFileTooFragmented = errors.New("tar2ext4: file needs more blocks than a single extent can address")
+)
+
+// builder owns the on-disk layout of the image being produced: the
+// superblock, a single block group's descriptor, its block/inode
+// bitmaps, and the inode table. It is intentionally simple (one block
+// group, blocks allocated linearly) rather than a general-purpose
+// resizeable-filesystem layout -- this mirrors the LCOW tar2ext4
+// approach of producing just enough of a real ext4 image to mount it.
+type builder struct {
+	out  io.WriteSeeker
+	opts Options
+
+	blockSize      uint32
+	blocksPerGroup uint32
+	inodesPerGroup uint32
+	inodeSize      uint16
+
+	blockBitmap []byte
+	inodeBitmap []byte
+	inodeTable  []byte
+
+	nextDataBlock uint32
+	nextInode     uint32
+
+	dirs  map[uint32]*directoryBuilder
+	paths map[string]uint32
+
+	firstDataBlock uint32
+	totalBlocks    uint32
+}
+
+func newBuilder(out io.WriteSeeker, opts Options) (b *builder, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	blockSize := opts.blockSize()
+
+	inodeCount := opts.InodeCount
+	if inodeCount == 0 {
+		inodeCount = 8192
+	}
+
+	b = &builder{
+		out:            out,
+		opts:           opts,
+		blockSize:      blockSize,
+		blocksPerGroup: blockSize * 8,
+		inodesPerGroup: inodeCount,
+		inodeSize:      defaultInodeSize,
+		inodeTable:     make([]byte, uint32(inodeCount)*defaultInodeSize),
+		nextInode:      firstFreeInode,
+		dirs:           make(map[uint32]*directoryBuilder),
+		paths:          make(map[string]uint32),
+	}
+
+	// Block 0 is the boot sector, block 1 (for a 1K block size) or
+	// block 0 itself (for larger block sizes) holds the superblock.
+	// We reserve the first few blocks for superblock + group
+	// descriptor + bitmaps + inode table, and start handing out data
+	// blocks after that.
+	if blockSize > 1024 {
+		b.firstDataBlock = 0
+	} else {
+		b.firstDataBlock = 1
+	}
+	metadataBlocks := 1 /* superblock+padding */ +
+		1 /* group descriptor */ +
+		1 /* block bitmap */ +
+		1 /* inode bitmap */ +
+		(uint32(inodeCount)*defaultInodeSize+blockSize-1)/blockSize
+
+	b.nextDataBlock = b.firstDataBlock + metadataBlocks
+	b.totalBlocks = b.blocksPerGroup
+
+	b.blockBitmap = make([]byte, b.blocksPerGroup/8)
+	b.inodeBitmap = make([]byte, b.inodesPerGroup/8)
+
+	for i := uint32(0); i < b.nextDataBlock; i++ {
+		setBit(b.blockBitmap, i)
+	}
+
+	for i := uint32(1); i < firstFreeInode; i++ {
+		setBit(b.inodeBitmap, i-1)
+	}
+
+	root := b.newDirectory(rootInode, rootInode, 0755)
+	b.dirs[rootInode] = root
+	b.paths["."] = rootInode
+
+	lf := b.newDirectory(lostAndFoundInode, rootInode, 0700)
+	b.dirs[lostAndFoundInode] = lf
+	root.addEntry("lost+found", lostAndFoundInode, ext2FtDir)
+
+	return b, nil
+}
+
+func setBit(bitmap []byte, bit uint32) {
+	bitmap[bit/8] |= 1 << (bit % 8)
+}
+
+func (b *builder) allocBlocks(n uint32) (first uint32, err error) {
+	if b.nextDataBlock+n > b.totalBlocks {
+		return 0, ErrImageTooLarge
+	}
+
+	first = b.nextDataBlock
+
+	for i := uint32(0); i < n; i++ {
+		setBit(b.blockBitmap, b.nextDataBlock+i)
+	}
+
+	b.nextDataBlock += n
+
+	return first, nil
+}
+
+func (b *builder) allocInode() (ino uint32, err error) {
+	if b.nextInode >= b.inodesPerGroup {
+		return 0, errors.New("tar2ext4: inode table exhausted")
+	}
+
+	ino = b.nextInode
+	setBit(b.inodeBitmap, ino-1)
+	b.nextInode++
+
+	return ino, nil
+}
+
+// inodeOffset returns the byte offset of inode `ino` within the inode
+// table.
+func (b *builder) inodeOffset(ino uint32) uint32 {
+	return (ino - 1) * uint32(b.inodeSize)
+}
+
+func (b *builder) dirOf(path string) (*directoryBuilder, string) {
+	dir := dirname(path)
+
+	parentIno, ok := b.paths[dir]
+	if !ok {
+		// tar streams are not guaranteed to emit a directory entry
+		// before files within it; materialize an implicit directory
+		// with reasonable default permissions, as GNU tar itself
+		// does on extract.
+		parentIno, _ = b.ensureDir(dir, 0755)
+	}
+
+	return b.dirs[parentIno], basename(path)
+}
+
+func (b *builder) ensureDir(path string, mode int64) (ino uint32, err error) {
+	if path == "." || path == "" {
+		return rootInode, nil
+	}
+
+	if existing, ok := b.paths[path]; ok {
+		return existing, nil
+	}
+
+	parent, name := b.dirOf(path)
+
+	ino, err = b.allocInode()
+	if err != nil {
+		return 0, err
+	}
+
+	dir := b.newDirectory(ino, parent.ino, mode)
+	b.dirs[ino] = dir
+	b.paths[path] = ino
+
+	parent.addEntry(name, ino, ext2FtDir)
+
+	return ino, nil
+}
+
+func (b *builder) addRegularFile(hdr *tar.Header, r io.Reader) error {
+	ino, err := b.allocInode()
+	if err != nil {
+		return err
+	}
+
+	extents, size, err := b.writeExtents(r, hdr.Size)
+	if err != nil {
+		return err
+	}
+
+	err = b.writeInode(ino, inodeParams{
+		mode:    ext2SIfreg | uint16(hdr.Mode&0xfff),
+		uid:     uint32(hdr.Uid),
+		gid:     uint32(hdr.Gid),
+		size:    uint64(size),
+		links:   1,
+		mtime:   hdr.ModTime,
+		extents: extents,
+	})
+	if err != nil {
+		return err
+	}
+
+	parent, name := b.dirOf(hdr.Name)
+	parent.addEntry(name, ino, ext2FtRegFile)
+	b.paths[normalizePath(hdr.Name)] = ino
+
+	return nil
+}
+
+func (b *builder) addDirectory(hdr *tar.Header) error {
+	path := normalizePath(hdr.Name)
+
+	ino, err := b.ensureDir(path, hdr.Mode)
+	if err != nil {
+		return err
+	}
+
+	return b.writeInode(ino, inodeParams{
+		mode:  ext2SIfdir | uint16(hdr.Mode&0xfff),
+		uid:   uint32(hdr.Uid),
+		gid:   uint32(hdr.Gid),
+		links: 2,
+		mtime: hdr.ModTime,
+	})
+}
+
+func (b *builder) addSymlink(hdr *tar.Header) error {
+	ino, err := b.allocInode()
+	if err != nil {
+		return err
+	}
+
+	params := inodeParams{
+		mode:  ext2SIflnk | 0777,
+		uid:   uint32(hdr.Uid),
+		gid:   uint32(hdr.Gid),
+		links: 1,
+		mtime: hdr.ModTime,
+		size:  uint64(len(hdr.Linkname)),
+	}
+
+	// Fast symlinks store the target directly in the inode's block
+	// pointer area (60 bytes) instead of a data block, same as the
+	// kernel does for targets that fit.
+	if len(hdr.Linkname) < 60 {
+		params.fastSymlink = []byte(hdr.Linkname)
+	} else {
+		extents, _, err := b.writeExtents(stringsReader(hdr.Linkname), int64(len(hdr.Linkname)))
+		if err != nil {
+			return err
+		}
+
+		params.extents = extents
+	}
+
+	err = b.writeInode(ino, params)
+	if err != nil {
+		return err
+	}
+
+	parent, name := b.dirOf(hdr.Name)
+	parent.addEntry(name, ino, ext2FtSymlink)
+	b.paths[normalizePath(hdr.Name)] = ino
+
+	return nil
+}
+
+func (b *builder) addHardlink(hdr *tar.Header) error {
+	target := normalizePath(hdr.Linkname)
+
+	ino, ok := b.paths[target]
+	if !ok {
+		return errors.New("tar2ext4: hardlink target not yet seen: " + hdr.Linkname)
+	}
+
+	parent, name := b.dirOf(hdr.Name)
+	parent.addEntry(name, ino, ext2FtRegFile)
+	b.paths[normalizePath(hdr.Name)] = ino
+
+	return b.bumpLinkCount(ino)
+}
+
+func (b *builder) addWhiteout(name string) error {
+	if isOpaqueWhiteout(name) {
+		dirPath := dirname(name)
+
+		ino, err := b.ensureDir(dirPath, 0755)
+		if err != nil {
+			return err
+		}
+
+		b.dirs[ino].opaque = true
+
+		return nil
+	}
+
+	target := whiteoutTarget(name)
+
+	ino, err := b.allocInode()
+	if err != nil {
+		return err
+	}
+
+	// A whiteout is represented on disk as a character device with a
+	// major/minor of 0/0, as the kernel's overlay/AUFS code expects.
+	err = b.writeInode(ino, inodeParams{
+		mode:  ext2SIfchr | 0,
+		links: 1,
+	})
+	if err != nil {
+		return err
+	}
+
+	parent, name := b.dirOf(target)
+	parent.addEntry(name, ino, ext2FtChrdev)
+
+	return nil
+}
+
+func (b *builder) finish() (err error) {
+	for ino, dir := range b.dirs {
+		extents, size, err := dir.flush(b)
+		if err != nil {
+			return err
+		}
+
+		mode := uint16(ext2SIfdir | 0755)
+		if ino == lostAndFoundInode {
+			mode = ext2SIfdir | 0700
+		}
+
+		links := uint16(2)
+
+		err = b.writeInode(ino, inodeParams{
+			mode:    mode,
+			links:   links,
+			size:    uint64(size),
+			extents: extents,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err = b.writeLayout()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// writeLayout flushes the bitmaps, inode table, group descriptor and
+// superblock to `out` at their fixed offsets.
+func (b *builder) writeLayout() error {
+	gdBlock := b.firstDataBlock + 1
+	blockBitmapBlock := gdBlock + 1
+	inodeBitmapBlock := blockBitmapBlock + 1
+	inodeTableBlock := inodeBitmapBlock + 1
+
+	if err := b.writeBlock(blockBitmapBlock, b.blockBitmap); err != nil {
+		return err
+	}
+
+	if err := b.writeBlock(inodeBitmapBlock, b.inodeBitmap); err != nil {
+		return err
+	}
+
+	if err := b.writeAt(int64(inodeTableBlock)*int64(b.blockSize), b.inodeTable); err != nil {
+		return err
+	}
+
+	gd := groupDescriptor{
+		BlockBitmapLo:     blockBitmapBlock,
+		InodeBitmapLo:     inodeBitmapBlock,
+		InodeTableLo:      inodeTableBlock,
+		FreeBlocksCountLo: uint16(b.totalBlocks - b.nextDataBlock),
+		FreeInodesCountLo: uint16(b.inodesPerGroup - b.nextInode + 1),
+		UsedDirsCountLo:   uint16(len(b.dirs)),
+	}
+
+	if err := b.writeAt(int64(gdBlock)*int64(b.blockSize), gd.bytes()); err != nil {
+		return err
+	}
+
+	sb := b.buildSuperblock()
+
+	return writeSuperblock(b.out, sb)
+}
+
+func (b *builder) buildSuperblock() *ext4.Superblock {
+	sb := new(ext4.Superblock)
+
+	sb.SMagic = ext4.Ext4Magic
+	sb.SInodesCount = b.inodesPerGroup
+	sb.SBlocksCountLo = b.totalBlocks
+	sb.SFreeBlocksCountLo = b.totalBlocks - b.nextDataBlock
+	sb.SFreeInodesCount = b.inodesPerGroup - b.nextInode + 1
+	sb.SFirstDataBlock = b.firstDataBlock
+	sb.SLogBlockSize = logBlockSize(b.blockSize)
+	sb.SBlocksPerGroup = b.blocksPerGroup
+	sb.SClustersPerGroup = b.blocksPerGroup
+	sb.SInodesPerGroup = b.inodesPerGroup
+	now := uint32(time.Now().Unix())
+	sb.SMtime = now
+	sb.SWtime = now
+	sb.SMaxMntCount = 0xffff
+	sb.SState = ext4.SbStateCleanlyUnmounted
+	sb.SErrors = ext4.SbErrorsContinue
+	sb.SCreatorOs = ext4.SbOsLinux
+	sb.SRevLevel = ext4.SbRevlevelDynamicRev
+	sb.SFirstIno = firstFreeInode
+	sb.SInodeSize = b.inodeSize
+	sb.SFeatureCompat = ext4.SbFeatureCompatDirIndex
+	sb.SFeatureIncompat = ext4.SbFeatureIncompatFiletype | ext4.SbFeatureIncompatExtents
+	sb.SFeatureRoCompat = ext4.SbFeatureRoCompatSparseSuper
+	sb.SLpfIno = lostAndFoundInode
+	sb.SDefHashVersion = 1
+
+	return sb
+}
+
+func logBlockSize(blockSize uint32) uint32 {
+	shift := uint32(0)
+
+	for (1 << (10 + shift)) < blockSize {
+		shift++
+	}
+
+	return shift
+}
+
+func (b *builder) writeBlock(block uint32, data []byte) error {
+	return b.writeAt(int64(block)*int64(b.blockSize), data)
+}
+
+func (b *builder) writeAt(offset int64, data []byte) error {
+	if _, err := b.out.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, err := b.out.Write(data)
+
+	return err
+}
+
+// writeSuperblock serializes `sb` using the same fixed layout
+// ParseSuperblock reads, so images produced here round-trip through the
+// rest of this module.
+func writeSuperblock(out io.WriteSeeker, sb *ext4.Superblock) error {
+	if _, err := out.Seek(ext4.Superblock0Offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	return binary.Write(out, binary.LittleEndian, sb)
+}
@@ -0,0 +1,138 @@
+package tar2ext4
+
+import (
+	"encoding/binary"
+)
+
+// dirEntry is one ext4 linear directory entry: inode, total record
+// length, name length, file type, and the name itself (padded by
+// recLen - 8 - len(name)).
+type dirEntry struct {
+	ino     uint32
+	name    string
+	fthType uint8
+}
+
+// directoryBuilder accumulates entries for one directory inode until
+// flush() lays it out as one or more directory blocks. htree indexing
+// is intentionally not produced: every directory this builder writes
+// is small enough that a linear scan (which the kernel always supports
+// as a fallback) is sufficient.
+type directoryBuilder struct {
+	ino    uint32
+	parent uint32
+	mode   int64
+	opaque bool
+
+	entries []dirEntry
+}
+
+func (b *builder) newDirectory(ino, parent uint32, mode int64) *directoryBuilder {
+	return &directoryBuilder{
+		ino:    ino,
+		parent: parent,
+		mode:   mode,
+	}
+}
+
+func (d *directoryBuilder) addEntry(name string, ino uint32, fthType uint8) {
+	d.entries = append(d.entries, dirEntry{ino: ino, name: name, fthType: fthType})
+}
+
+// flush serializes "." and ".." plus every accumulated entry into
+// directory blocks and returns the extents backing them along with the
+// total size in bytes.
+func (d *directoryBuilder) flush(b *builder) ([]extent, int64, error) {
+	all := make([]dirEntry, 0, len(d.entries)+2)
+	all = append(all, dirEntry{ino: d.ino, name: ".", fthType: ext2FtDir})
+	all = append(all, dirEntry{ino: d.parent, name: "..", fthType: ext2FtDir})
+	all = append(all, d.entries...)
+
+	blockSize := int(b.blockSize)
+	blocks := make([][]byte, 0, 1)
+	cur := make([]byte, blockSize)
+	offset := 0
+
+	flushBlock := func() {
+		blocks = append(blocks, cur)
+		cur = make([]byte, blockSize)
+		offset = 0
+	}
+
+	for i, e := range all {
+		recLen := dirEntryLen(e.name)
+		last := i == len(all)-1
+
+		// The last entry in a block always extends to the end of the
+		// block, exactly as the kernel's directory code expects so it
+		// can find the end of the chain by record length alone. This
+		// applies to the last entry written into every block, not just
+		// the directory's last entry overall.
+		remaining := blockSize - offset
+
+		if recLen > remaining && offset > 0 {
+			flushBlock()
+			remaining = blockSize
+		}
+
+		lastInBlock := last
+		if !last {
+			nextLen := dirEntryLen(all[i+1].name)
+			lastInBlock = nextLen > remaining-recLen
+		}
+
+		useLen := recLen
+		if lastInBlock {
+			useLen = remaining
+		}
+
+		putDirEntry(cur[offset:offset+useLen], e, useLen)
+		offset += useLen
+	}
+
+	flushBlock()
+
+	extents, err := b.writeDirBlocks(blocks)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return extents, int64(len(blocks) * blockSize), nil
+}
+
+func (b *builder) writeDirBlocks(blocks [][]byte) ([]extent, error) {
+	first, err := b.allocBlocks(uint32(len(blocks)))
+	if err != nil {
+		return nil, err
+	}
+
+	for i, block := range blocks {
+		if err := b.writeBlock(first+uint32(i), block); err != nil {
+			return nil, err
+		}
+	}
+
+	return []extent{
+		{
+			firstLogicalBlock:  0,
+			blockCount:         uint16(len(blocks)),
+			firstPhysicalBlock: first,
+		},
+	}, nil
+}
+
+// dirEntryLen returns the record length a directory entry for `name`
+// needs, rounded up to the kernel's 4-byte alignment.
+func dirEntryLen(name string) int {
+	raw := 8 + len(name)
+
+	return (raw + 3) &^ 3
+}
+
+func putDirEntry(raw []byte, e dirEntry, recLen int) {
+	binary.LittleEndian.PutUint32(raw[0:4], e.ino)
+	binary.LittleEndian.PutUint16(raw[4:6], uint16(recLen))
+	raw[6] = uint8(len(e.name))
+	raw[7] = e.fthType
+	copy(raw[8:], e.name)
+}
@@ -0,0 +1,33 @@
+package tar2ext4
+
+import "encoding/binary"
+
+// groupDescriptor is the subset of ext4_group_desc this single-group
+// builder needs to fill in; the 64bit half (the *Hi fields) is left
+// zero since the image this package produces never exceeds 32-bit
+// block counts.
+type groupDescriptor struct {
+	BlockBitmapLo     uint32
+	InodeBitmapLo     uint32
+	InodeTableLo      uint32
+	FreeBlocksCountLo uint16
+	FreeInodesCountLo uint16
+	UsedDirsCountLo   uint16
+}
+
+// groupDescriptorSize matches the 32-byte (non-64bit) ext4_group_desc
+// layout.
+const groupDescriptorSize = 32
+
+func (gd groupDescriptor) bytes() []byte {
+	raw := make([]byte, groupDescriptorSize)
+
+	binary.LittleEndian.PutUint32(raw[0:4], gd.BlockBitmapLo)
+	binary.LittleEndian.PutUint32(raw[4:8], gd.InodeBitmapLo)
+	binary.LittleEndian.PutUint32(raw[8:12], gd.InodeTableLo)
+	binary.LittleEndian.PutUint16(raw[12:14], gd.FreeBlocksCountLo)
+	binary.LittleEndian.PutUint16(raw[14:16], gd.FreeInodesCountLo)
+	binary.LittleEndian.PutUint16(raw[16:18], gd.UsedDirsCountLo)
+
+	return raw
+}
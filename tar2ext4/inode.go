@@ -0,0 +1,163 @@
+package tar2ext4
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+// ext2 inode mode bits (the high nibble of i_mode) and directory-entry
+// file-type values. These mirror <linux/fs.h> / <linux/ext2_fs.h> but
+// only the handful this builder emits are defined here, rather than
+// duplicating the whole set in the main package.
+const (
+	ext2SIfreg = 0x8000
+	ext2SIfdir = 0x4000
+	ext2SIflnk = 0xA000
+	ext2SIfchr = 0x2000
+)
+
+const (
+	ext2FtUnknown = 0
+	ext2FtRegFile = 1
+	ext2FtDir     = 2
+	ext2FtChrdev  = 3
+	ext2FtSymlink = 7
+)
+
+// extentsPerInode is how many inline extents fit in the 60-byte
+// i_block area of an inode once the 12-byte extent header and one
+// extent-tree entry size is accounted for; this builder never grows a
+// file past a single inline extent leaf (4 entries), which keeps the
+// tree depth at zero. writeExtents and writeDirBlocks each emit exactly
+// one extent, so only the first of those entries is ever used.
+const extentsPerInode = 4
+
+// extent is a logical-to-physical block run, the same shape as the
+// on-disk ext4_extent struct.
+type extent struct {
+	firstLogicalBlock  uint32
+	blockCount         uint16
+	firstPhysicalBlock uint32
+}
+
+type inodeParams struct {
+	mode  uint16
+	uid   uint32
+	gid   uint32
+	size  uint64
+	links uint16
+	mtime time.Time
+
+	extents     []extent
+	fastSymlink []byte
+}
+
+// writeInode serializes an inode's fixed fields and its extent tree (or
+// fast-symlink target) into the in-memory inode table at the slot
+// reserved by allocInode.
+func (b *builder) writeInode(ino uint32, p inodeParams) error {
+	off := b.inodeOffset(ino)
+	raw := b.inodeTable[off : off+uint32(b.inodeSize)]
+
+	binary.LittleEndian.PutUint16(raw[0:2], p.mode)
+	binary.LittleEndian.PutUint16(raw[2:4], uint16(p.uid))
+	binary.LittleEndian.PutUint32(raw[4:8], uint32(p.size))
+	binary.LittleEndian.PutUint32(raw[8:12], uint32(p.mtime.Unix()))
+	binary.LittleEndian.PutUint32(raw[12:16], uint32(p.mtime.Unix()))
+	binary.LittleEndian.PutUint32(raw[16:20], uint32(p.mtime.Unix()))
+	binary.LittleEndian.PutUint16(raw[24:26], uint16(p.gid))
+	binary.LittleEndian.PutUint16(raw[26:28], p.links)
+	binary.LittleEndian.PutUint32(raw[108:112], uint32(p.size>>32))
+
+	iBlock := raw[40:100]
+
+	switch {
+	case len(p.fastSymlink) > 0:
+		copy(iBlock, p.fastSymlink)
+	case len(p.extents) > 0:
+		// EXT4_EXTENTS_FL so the kernel knows i_block holds an extent
+		// tree header rather than direct/indirect block pointers.
+		binary.LittleEndian.PutUint32(raw[32:36], 0x80000)
+		writeExtentHeader(iBlock, p.extents)
+	}
+
+	return nil
+}
+
+func (b *builder) bumpLinkCount(ino uint32) error {
+	off := b.inodeOffset(ino)
+	raw := b.inodeTable[off : off+uint32(b.inodeSize)]
+
+	links := binary.LittleEndian.Uint16(raw[26:28])
+	binary.LittleEndian.PutUint16(raw[26:28], links+1)
+
+	return nil
+}
+
+// writeExtentHeader lays out the ext4_extent_header followed by up to
+// extentsPerInode ext4_extent entries directly in an inode's i_block
+// area. Depth is always 0: this builder never needs an index node
+// because it caps how many extents a single file can have.
+func writeExtentHeader(iBlock []byte, extents []extent) {
+	const extentMagic = 0xf30a
+
+	binary.LittleEndian.PutUint16(iBlock[0:2], extentMagic)
+	binary.LittleEndian.PutUint16(iBlock[2:4], uint16(len(extents)))
+	binary.LittleEndian.PutUint16(iBlock[4:6], extentsPerInode)
+	binary.LittleEndian.PutUint16(iBlock[6:8], 0) // depth
+
+	for i, e := range extents {
+		entry := iBlock[12+i*12 : 12+(i+1)*12]
+
+		binary.LittleEndian.PutUint32(entry[0:4], e.firstLogicalBlock)
+		binary.LittleEndian.PutUint16(entry[4:6], e.blockCount)
+		binary.LittleEndian.PutUint16(entry[6:8], 0) // physical block high bits; this builder stays within 32-bit addressing
+		binary.LittleEndian.PutUint32(entry[8:12], e.firstPhysicalBlock)
+	}
+}
+
+// writeExtents allocates enough blocks to hold `size` bytes read from
+// `r`, writes the data, and returns the extent list describing where it
+// landed. allocBlocks always hands back one contiguous run, so this
+// always emits a single extent rather than needing to coalesce several
+// -- but an ext4_extent can only describe up to 32768 blocks, so a file
+// larger than that (possible once a caller raises Options.BlockSize
+// past the default 4K) doesn't fit in one and is rejected rather than
+// silently truncated.
+func (b *builder) writeExtents(r io.Reader, size int64) ([]extent, int64, error) {
+	if size == 0 {
+		return nil, 0, nil
+	}
+
+	blockCount := uint32((size + int64(b.blockSize) - 1) / int64(b.blockSize))
+	if blockCount > 32768 {
+		return nil, 0, ErrFileTooFragmented
+	}
+
+	first, err := b.allocBlocks(blockCount)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	buf := make([]byte, size)
+
+	n, err := io.ReadFull(r, buf)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, 0, err
+	}
+
+	if err := b.writeAt(int64(first)*int64(b.blockSize), buf[:n]); err != nil {
+		return nil, 0, err
+	}
+
+	extents := []extent{
+		{
+			firstLogicalBlock:  0,
+			blockCount:         uint16(blockCount),
+			firstPhysicalBlock: first,
+		},
+	}
+
+	return extents, int64(n), nil
+}
@@ -0,0 +1,78 @@
+package tar2ext4
+
+import (
+	"io"
+	"strings"
+)
+
+// normalizePath strips tar's conventions (leading "./", trailing "/")
+// down to the form this builder uses as a lookup key in b.paths.
+func normalizePath(name string) string {
+	name = strings.TrimSuffix(name, "/")
+	name = strings.TrimPrefix(name, "./")
+	name = strings.TrimPrefix(name, "/")
+
+	if name == "" {
+		name = "."
+	}
+
+	return name
+}
+
+func dirname(path string) string {
+	path = normalizePath(path)
+
+	i := strings.LastIndex(path, "/")
+	if i == -1 {
+		return "."
+	}
+
+	return path[:i]
+}
+
+func basename(path string) string {
+	path = normalizePath(path)
+
+	i := strings.LastIndex(path, "/")
+	if i == -1 {
+		return path
+	}
+
+	return path[i+1:]
+}
+
+// whiteoutTarget turns an AUFS whiteout marker's tar path
+// ("dir/.wh.foo") into the path of the entry it deletes ("dir/foo").
+func whiteoutTarget(name string) string {
+	dir := dirname(name)
+	base := basename(name)
+	base = strings.TrimPrefix(base, ".wh.")
+
+	if dir == "." {
+		return base
+	}
+
+	return dir + "/" + base
+}
+
+// stringsReader is a tiny io.Reader over a string, used for symlink
+// targets too long to store inline in the inode.
+type stringsReaderType struct {
+	s   string
+	pos int
+}
+
+func stringsReader(s string) *stringsReaderType {
+	return &stringsReaderType{s: s}
+}
+
+func (r *stringsReaderType) Read(p []byte) (int, error) {
+	if r.pos >= len(r.s) {
+		return 0, io.EOF
+	}
+
+	n := copy(p, r.s[r.pos:])
+	r.pos += n
+
+	return n, nil
+}
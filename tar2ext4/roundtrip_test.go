@@ -0,0 +1,129 @@
+package tar2ext4_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/hellin/go-ext4/ext4fs"
+	"github.com/hellin/go-ext4/tar2ext4"
+)
+
+// buildImage tars up `files` (path -> content) and converts the result
+// into a fresh ext4 image, returning the path to it.
+func buildImage(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	var tarBuf bytes.Buffer
+	tw := tar.NewWriter(&tarBuf)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		content := files[name]
+
+		if err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Mode: 0644,
+			Size: int64(len(content)),
+		}); err != nil {
+			t.Fatalf("WriteHeader(%s): %v", name, err)
+		}
+
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("Write(%s): %v", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatalf("tar Close: %v", err)
+	}
+
+	imgPath := filepath.Join(t.TempDir(), "image.ext4")
+
+	out, err := os.Create(imgPath)
+	if err != nil {
+		t.Fatalf("create image: %v", err)
+	}
+	defer out.Close()
+
+	if err := tar2ext4.Convert(&tarBuf, out, tar2ext4.Options{}); err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+
+	return imgPath
+}
+
+// TestRoundTrip builds a small image with tar2ext4 and reads it back
+// with ext4fs, the combination the whole point of both packages is to
+// support, and checks both file contents and directory listing order.
+func TestRoundTrip(t *testing.T) {
+	files := map[string]string{
+		"zzz": "last alphabetically",
+		"aaa": "first alphabetically",
+		"mmm": "middle",
+	}
+
+	imgPath := buildImage(t, files)
+
+	img, err := os.Open(imgPath)
+	if err != nil {
+		t.Fatalf("open image: %v", err)
+	}
+	defer img.Close()
+
+	fsys, err := ext4fs.Open(img)
+	if err != nil {
+		t.Fatalf("ext4fs.Open: %v", err)
+	}
+
+	for name, want := range files {
+		got, err := fsys.ReadFile(name)
+		if err != nil {
+			t.Errorf("ReadFile(%s): %v", name, err)
+			continue
+		}
+
+		if string(got) != want {
+			t.Errorf("ReadFile(%s) = %q, want %q", name, got, want)
+		}
+	}
+
+	entries, err := fsys.ReadDir(".")
+	if err != nil {
+		t.Fatalf("ReadDir(.): %v", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("ReadDir(.) returned unsorted entries: %v", names)
+	}
+
+	want := []string{"aaa", "lost+found", "mmm", "zzz"}
+	if len(names) != len(want) {
+		t.Fatalf("ReadDir(.) = %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Errorf("ReadDir(.)[%d] = %q, want %q", i, names[i], want[i])
+		}
+	}
+
+	if err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		return err
+	}); err != nil {
+		t.Errorf("fs.WalkDir: %v", err)
+	}
+}
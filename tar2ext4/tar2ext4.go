@@ -0,0 +1,192 @@
+// Package tar2ext4 builds a mountable ext4 filesystem image from a tar
+// stream, following the approach used by Microsoft's LCOW graphdriver:
+// walk the tar entries once, lay out inodes/extents/directories as they
+// arrive, and flush a complete superblock/group-descriptor table at the
+// end.
+package tar2ext4
+
+import (
+	"archive/tar"
+	"errors"
+	"io"
+	"strings"
+
+	"github.com/dsoprea/go-logging"
+)
+
+var (
+	// ErrNotRegularOrDir is returned by AddFile for tar entry types this
+	// package doesn't know how to place on disk yet (devices, fifos, etc).
+	ErrNotRegularOrDir = errors.New("tar2ext4: unsupported tar entry type")
+)
+
+// Options controls how the image is built.
+type Options struct {
+	// ConvertWhiteouts rewrites AUFS-style whiteout entries (`.wh.foo`)
+	// into ext4 char-device (0/0) whiteouts instead of copying them
+	// through verbatim. An opaque-directory marker (`.wh..wh..opq`)
+	// only makes sure its directory exists and is then dropped: no
+	// opaque xattr is written for that directory.
+	ConvertWhiteouts bool
+
+	// BlockSize is the filesystem block size in bytes. Defaults to 4096.
+	BlockSize uint32
+
+	// InodeCount is the number of inodes to reserve in the image. If
+	// zero, 8192 inodes are reserved.
+	InodeCount uint32
+}
+
+func (o Options) blockSize() uint32 {
+	if o.BlockSize == 0 {
+		return 4096
+	}
+
+	return o.BlockSize
+}
+
+// Converter incrementally builds an ext4 image from tar entries. Callers
+// that already have a *tar.Reader in hand (rather than a plain
+// io.Reader) can drive it directly via AddFile; Convert is a convenience
+// wrapper around the common case of consuming a whole tar stream.
+type Converter struct {
+	out  io.WriteSeeker
+	opts Options
+
+	fs *builder
+}
+
+// NewConverter allocates the on-disk layout (group descriptors, bitmaps,
+// inode table) and returns a Converter ready to accept tar entries via
+// AddFile.
+func NewConverter(out io.WriteSeeker, opts Options) (c *Converter, err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	fs, err := newBuilder(out, opts)
+	log.PanicIf(err)
+
+	c = &Converter{
+		out:  out,
+		opts: opts,
+		fs:   fs,
+	}
+
+	return c, nil
+}
+
+// AddFile places a single tar entry on disk: it allocates an inode,
+// writes the entry's data (if any) through an extent tree, and adds a
+// directory entry for it in its parent directory (which is created
+// on-demand, mirroring how tar streams may reference a file before its
+// containing directory entry appears).
+func (c *Converter) AddFile(hdr *tar.Header, r io.Reader) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	name := strings.TrimSuffix(hdr.Name, "/")
+
+	if c.opts.ConvertWhiteouts && isWhiteout(name) {
+		err := c.fs.addWhiteout(name)
+		log.PanicIf(err)
+
+		return nil
+	}
+
+	switch hdr.Typeflag {
+	case tar.TypeReg, tar.TypeRegA:
+		err := c.fs.addRegularFile(hdr, r)
+		log.PanicIf(err)
+	case tar.TypeDir:
+		err := c.fs.addDirectory(hdr)
+		log.PanicIf(err)
+	case tar.TypeSymlink:
+		err := c.fs.addSymlink(hdr)
+		log.PanicIf(err)
+	case tar.TypeLink:
+		err := c.fs.addHardlink(hdr)
+		log.PanicIf(err)
+	default:
+		log.Panic(ErrNotRegularOrDir)
+	}
+
+	return nil
+}
+
+// Finish writes the lost+found directory, the inode/block bitmaps, the
+// group descriptor table, and the primary superblock, leaving out a
+// complete, mountable image. The image has a single block group, so no
+// backup superblock copies are written.
+func (c *Converter) Finish() (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	err = c.fs.finish()
+	log.PanicIf(err)
+
+	return nil
+}
+
+// Convert reads a tar stream from `r` and writes a complete ext4 image
+// to `out` in a single call. It is equivalent to driving a Converter
+// with NewConverter/AddFile/Finish over each entry in `r`.
+func Convert(r io.Reader, out io.WriteSeeker, opts Options) (err error) {
+	defer func() {
+		if state := recover(); state != nil {
+			err = log.Wrap(state.(error))
+		}
+	}()
+
+	c, err := NewConverter(out, opts)
+	log.PanicIf(err)
+
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+
+		log.PanicIf(err)
+
+		err = c.AddFile(hdr, tr)
+		log.PanicIf(err)
+	}
+
+	err = c.Finish()
+	log.PanicIf(err)
+
+	return nil
+}
+
+// isWhiteout returns true for AUFS-style whiteout markers: `.wh.<name>`
+// (a deleted entry) and `.wh..wh..opq` (an opaque directory marker).
+func isWhiteout(name string) bool {
+	base := name
+
+	if i := strings.LastIndex(name, "/"); i != -1 {
+		base = name[i+1:]
+	}
+
+	return strings.HasPrefix(base, ".wh.")
+}
+
+func isOpaqueWhiteout(name string) bool {
+	base := name
+
+	if i := strings.LastIndex(name, "/"); i != -1 {
+		base = name[i+1:]
+	}
+
+	return base == ".wh..wh..opq"
+}